@@ -0,0 +1,28 @@
+package scans
+
+import "testing"
+
+func TestCancelScanUnknownID(t *testing.T) {
+	s := &ScanService{}
+
+	if s.CancelScan(42) {
+		t.Fatal("expected CancelScan to return false for a scan ID that was never stored")
+	}
+}
+
+func TestCancelScanCancelsRunningScan(t *testing.T) {
+	s := &ScanService{}
+
+	cancelled := false
+	s.Running.Store(int64(1), runningScan{
+		percent: func() float64 { return 0 },
+		cancel:  func() { cancelled = true },
+	})
+
+	if !s.CancelScan(1) {
+		t.Fatal("expected CancelScan to return true for a running scan")
+	}
+	if !cancelled {
+		t.Fatal("expected CancelScan to invoke the stored cancel func")
+	}
+}
@@ -0,0 +1,91 @@
+package scans
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// progressSampleInterval is how often a running scan's completion
+// percentage is sampled into the nuclei_scan_progress_percent gauge.
+const progressSampleInterval = 5 * time.Second
+
+var (
+	templatesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nuclei_scan_templates_total",
+		Help: "Total number of templates executed across all scans.",
+	})
+	requestsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nuclei_scan_requests_total",
+		Help: "Total number of protocol requests issued across all scans.",
+	})
+	matchesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nuclei_scan_matches_total",
+		Help: "Total number of template matches found across all scans.",
+	})
+	errorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nuclei_scan_errors_total",
+		Help: "Total number of errors encountered across all scans.",
+	})
+	progressPercent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nuclei_scan_progress_percent",
+		Help: "Completion percentage of a running scan.",
+	}, []string{"scan_id"})
+	scanDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "nuclei_scan_duration_seconds",
+		Help:    "Duration of completed scans in seconds.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 15),
+	})
+)
+
+// MetricsHandler returns the HTTP handler that serves the collected scan
+// metrics in Prometheus exposition format. Callers are responsible for
+// mounting it on the web API server's own router at /metrics - it is not
+// registered automatically, since this package has no way to know whether
+// the server uses http.DefaultServeMux or its own, nor what auth that
+// route needs to sit behind.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// sampleProgress periodically records scanCtx's completion percentage into
+// the progressPercent gauge, until done is closed, at which point the
+// gauge's label is removed so it doesn't leak across scans.
+func (s *ScanService) sampleProgress(scanCtx *scanContext, done <-chan struct{}) {
+	scanIDLabel := strconv.FormatInt(scanCtx.scanID, 10)
+	defer progressPercent.DeleteLabelValues(scanIDLabel)
+
+	ticker := time.NewTicker(progressSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			progressPercent.WithLabelValues(scanIDLabel).Set(scanCtx.executerOpts.Progress.Percent())
+		case <-done:
+			return
+		}
+	}
+}
+
+// recordScanMetrics folds a finished scan's progress metrics into the
+// aggregate Prometheus counters and records its duration.
+func recordScanMetrics(metrics map[string]interface{}, duration time.Duration) {
+	if v, ok := metrics["templates"].(int64); ok {
+		templatesTotal.Add(float64(v))
+	}
+	if v, ok := metrics["requests"].(int64); ok {
+		requestsTotal.Add(float64(v))
+	}
+	if v, ok := metrics["matched"].(int64); ok {
+		matchesTotal.Add(float64(v))
+	}
+	if v, ok := metrics["errors"].(int64); ok {
+		errorsTotal.Add(float64(v))
+	}
+	scanDuration.Observe(duration.Seconds())
+}
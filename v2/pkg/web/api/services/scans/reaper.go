@@ -0,0 +1,63 @@
+package scans
+
+import (
+	"context"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// ReapOrphanScans looks for scans stuck in the "running" state whose
+// heartbeat has not been renewed within orphanHeartbeatThreshold - for
+// example because nuclei-web crashed or was restarted mid-scan - and either
+// requeues them for re-execution or marks them failed if the worker queue
+// is full.
+//
+// This should be called once on ScanService startup, before the worker
+// pool starts picking up new requests.
+func (s *ScanService) ReapOrphanScans(ctx context.Context) error {
+	orphaned, err := s.db.GetStaleRunningScans(ctx, staleBeforeThreshold(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	for _, scan := range orphaned {
+		req := ScanRequest{
+			ScanID:    scan.ID,
+			Config:    scan.Config,
+			Templates: scan.Templates,
+			Targets:   scan.Targets,
+			Webhook:   scan.Webhook,
+		}
+
+		if s.tryRequeue(req) {
+			gologger.Info().Msgf("[scans] [reaper] [%d] requeued orphaned scan with stale heartbeat", scan.ID)
+			continue
+		}
+
+		gologger.Warning().Msgf("[scans] [reaper] [%d] worker queue full, marking orphaned scan as failed", scan.ID)
+		if markErr := s.db.UpdateScanState(ctx, scan.ID, "failed"); markErr != nil {
+			gologger.Warning().Msgf("[scans] [reaper] [%d] could not mark orphaned scan as failed: %s", scan.ID, markErr)
+		}
+	}
+	return nil
+}
+
+// staleBeforeThreshold returns the heartbeat cutoff time: a "running" scan
+// whose last_heartbeat_at is older than this is considered orphaned.
+// Taking now as a parameter keeps the threshold calculation deterministic
+// and testable.
+func staleBeforeThreshold(now time.Time) time.Time {
+	return now.Add(-orphanHeartbeatThreshold)
+}
+
+// tryRequeue attempts a non-blocking send of req onto the worker queue,
+// returning true if it was accepted.
+func (s *ScanService) tryRequeue(req ScanRequest) bool {
+	select {
+	case s.Scans <- req:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,33 @@
+package scans
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleBeforeThreshold(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	got := staleBeforeThreshold(now)
+	want := now.Add(-orphanHeartbeatThreshold)
+
+	if !got.Equal(want) {
+		t.Fatalf("staleBeforeThreshold(%v) = %v, want %v", now, got, want)
+	}
+}
+
+func TestTryRequeue(t *testing.T) {
+	s := &ScanService{Scans: make(chan ScanRequest, 1)}
+
+	if !s.tryRequeue(ScanRequest{ScanID: 1}) {
+		t.Fatal("expected tryRequeue to accept a request into a queue with spare capacity")
+	}
+	if s.tryRequeue(ScanRequest{ScanID: 2}) {
+		t.Fatal("expected tryRequeue to return false once the queue is full")
+	}
+
+	queued := <-s.Scans
+	if queued.ScanID != 1 {
+		t.Fatalf("expected the first requeued scan to be ID 1, got %d", queued.ScanID)
+	}
+}
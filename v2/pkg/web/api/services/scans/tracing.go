@@ -0,0 +1,56 @@
+package scans
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// tracer emits spans for the scan worker pipeline, one trace per scan with
+// a child span per phase (settings-load, templates-load, input-load,
+// execute) so operators get latency breakdowns that gologger.Info lines
+// alone can't provide.
+var tracer = otel.Tracer("github.com/projectdiscovery/nuclei/v2/pkg/web/api/services/scans")
+
+// TracingConfig configures the OTLP exporter used to publish scan traces.
+// Its fields carry yaml tags, but nothing in this package loads it from the
+// settings.Settings YAML: NewTracerProvider takes it as an argument.
+type TracingConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	OTLPEndpoint  string            `yaml:"otlp_endpoint"`
+	Headers       map[string]string `yaml:"headers"`
+	SamplingRatio float64           `yaml:"sampling_ratio"`
+}
+
+// NewTracerProvider builds an OTel TracerProvider exporting spans to the
+// OTLP endpoint described by config and installs it as the global
+// provider, so scanContext.ctx spans created downstream are exported.
+// Callers should defer the returned shutdown func to flush pending spans.
+// If tracing is disabled, a no-op shutdown func is returned.
+func NewTracerProvider(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx,
+		otlptracegrpc.WithEndpoint(config.OTLPEndpoint),
+		otlptracegrpc.WithHeaders(config.Headers),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := config.SamplingRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
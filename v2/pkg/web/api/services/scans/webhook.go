@@ -0,0 +1,309 @@
+package scans
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/nuclei/v2/pkg/output"
+)
+
+const (
+	// webhookFindingsBatchSize is how many findings accumulate before
+	// webhookOutputWriter flushes them as a single scan.finding delivery,
+	// regardless of webhookFindingsFlushInterval.
+	webhookFindingsBatchSize = 50
+	// webhookFindingsFlushInterval is the longest a finding waits in the
+	// batch before being flushed, so deliveries still happen promptly for
+	// scans that produce matches slowly.
+	webhookFindingsFlushInterval = 5 * time.Second
+)
+
+// WebhookConfig configures the lifecycle webhook notifications fired for a
+// scan. It is read from the scan request only; there is no settings-level
+// default. A zero-value WebhookConfig disables delivery.
+type WebhookConfig struct {
+	URL         string        `yaml:"url" json:"url"`
+	Secret      string        `yaml:"secret" json:"secret"`
+	MaxRetries  int           `yaml:"max_retries" json:"max_retries"`
+	BackoffBase time.Duration `yaml:"backoff_base" json:"backoff_base"`
+	// Fields, when non-empty, caps the webhook payload to only the named
+	// output.ResultEvent fields instead of serializing the whole event.
+	Fields []string `yaml:"fields" json:"fields"`
+}
+
+// Redacted returns a copy of w with Secret cleared. Callers building an API
+// response that echoes a scan's request back to a client (e.g. a "get scan"
+// handler) must serialize Redacted(), never w itself, so the HMAC signing
+// secret is never leaked to a client. Internal round-trips of a ScanRequest
+// - such as reloading scan.Webhook from the DB row to requeue an orphaned
+// scan - must keep using w directly, or the secret can't survive a reload
+// and webhook signing silently breaks after a crash recovery.
+func (w WebhookConfig) Redacted() WebhookConfig {
+	w.Secret = ""
+	return w
+}
+
+const (
+	defaultWebhookMaxRetries  = 5
+	defaultWebhookBackoffBase = 500 * time.Millisecond
+)
+
+// webhookEventType identifies a scan lifecycle transition.
+type webhookEventType string
+
+const (
+	webhookEventQueued    webhookEventType = "scan.queued"
+	webhookEventStarted   webhookEventType = "scan.started"
+	webhookEventFinding   webhookEventType = "scan.finding"
+	webhookEventCompleted webhookEventType = "scan.completed"
+	webhookEventFailed    webhookEventType = "scan.failed"
+	webhookEventCancelled webhookEventType = "scan.cancelled"
+)
+
+// webhookPayload is the JSON body POSTed to the configured webhook URL. A
+// scan.finding delivery batches one or more matches into Findings instead
+// of firing one delivery per match - see webhookOutputWriter.
+type webhookPayload struct {
+	Event    webhookEventType `json:"event"`
+	ScanID   int64            `json:"scan_id"`
+	Findings []webhookFinding `json:"findings,omitempty"`
+}
+
+// webhookFinding is a single match reported in a scan.finding delivery.
+type webhookFinding struct {
+	Target     string              `json:"target,omitempty"`
+	TemplateID string              `json:"template_id,omitempty"`
+	Severity   string              `json:"severity,omitempty"`
+	Result     *output.ResultEvent `json:"result,omitempty"`
+}
+
+// webhookNotifier delivers scan lifecycle and finding notifications to a
+// configured webhook URL, retrying failed deliveries with exponential
+// backoff and jitter. A notifier with an empty URL is a no-op.
+type webhookNotifier struct {
+	scanID int64
+	config WebhookConfig
+	client *http.Client
+}
+
+func newWebhookNotifier(scanID int64, config WebhookConfig) *webhookNotifier {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultWebhookMaxRetries
+	}
+	if config.BackoffBase == 0 {
+		config.BackoffBase = defaultWebhookBackoffBase
+	}
+	return &webhookNotifier{
+		scanID: scanID,
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// notify fires a lifecycle event asynchronously so callers are never
+// blocked on webhook delivery.
+func (w *webhookNotifier) notify(event webhookEventType, payload webhookPayload) {
+	if w == nil || w.config.URL == "" {
+		return
+	}
+	payload.Event = event
+	payload.ScanID = w.scanID
+	go w.deliverWithRetry(event, w.selectFields(payload))
+}
+
+// selectFields caps the payload to the configured field selector, if any,
+// so large ResultEvent bodies don't bloat every webhook delivery.
+func (w *webhookNotifier) selectFields(payload webhookPayload) interface{} {
+	if len(w.config.Fields) == 0 {
+		return payload
+	}
+
+	full, err := json.Marshal(payload)
+	if err != nil {
+		return payload
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(full, &fields); err != nil {
+		return payload
+	}
+
+	allowed := map[string]bool{"event": true, "scan_id": true}
+	for _, field := range w.config.Fields {
+		allowed[field] = true
+	}
+	for key := range fields {
+		if !allowed[key] {
+			delete(fields, key)
+		}
+	}
+	return fields
+}
+
+func (w *webhookNotifier) deliverWithRetry(event webhookEventType, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		gologger.Warning().Msgf("[scans] [webhook] [%d] could not marshal %s payload: %s", w.scanID, event, err)
+		return
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < w.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := w.config.BackoffBase * time.Duration(1<<uint(attempt-1))
+			jitter := time.Duration(rand.Int63n(int64(backoff) + 1))
+			time.Sleep(backoff + jitter)
+		}
+		if lastErr = w.deliver(body); lastErr == nil {
+			return
+		}
+	}
+	gologger.Warning().Msgf("[scans] [webhook] [%d] giving up delivering %s after %d attempts: %s", w.scanID, event, w.config.MaxRetries, lastErr)
+}
+
+func (w *webhookNotifier) deliver(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.config.Secret != "" {
+		req.Header.Set("X-Nuclei-Signature", w.sign(body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sign computes the hex-encoded HMAC-SHA256 signature of body using the
+// configured secret, so receivers can verify the webhook's authenticity.
+func (w *webhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.config.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookOutputWriter wraps an output.Writer so that matches written
+// during the scan are also streamed to the configured webhook as batched
+// scan.finding events, in addition to being persisted as before. Matches
+// are coalesced rather than delivered one-by-one so a scan with thousands
+// of matches doesn't spawn thousands of concurrent delivery goroutines.
+type webhookOutputWriter struct {
+	output.Writer
+	notifier *webhookNotifier
+
+	mu            sync.Mutex
+	pending       []webhookFinding
+	flush         chan struct{}
+	stop          chan struct{}
+	stopOnce      sync.Once
+	batchLoopDone chan struct{}
+}
+
+// newWebhookOutputWriter composes webhook delivery alongside an existing
+// output.Writer (such as the one produced by newWrappedOutputWriter), so
+// findings are streamed out as they arrive rather than only at end of scan.
+func newWebhookOutputWriter(inner output.Writer, notifier *webhookNotifier) output.Writer {
+	if notifier == nil || notifier.config.URL == "" {
+		return inner
+	}
+	w := &webhookOutputWriter{
+		Writer:        inner,
+		notifier:      notifier,
+		flush:         make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		batchLoopDone: make(chan struct{}),
+	}
+	go w.batchLoop()
+	return w
+}
+
+func (w *webhookOutputWriter) Write(event *output.ResultEvent) error {
+	err := w.Writer.Write(event)
+	if err == nil {
+		w.enqueue(webhookFinding{
+			Target:     event.Host,
+			TemplateID: event.TemplateID,
+			Severity:   event.Info.SeverityHolder.Severity.String(),
+			Result:     event,
+		})
+	}
+	return err
+}
+
+// enqueue adds finding to the pending batch, requesting an out-of-band
+// flush once the batch reaches webhookFindingsBatchSize instead of waiting
+// for the next flush-interval tick.
+func (w *webhookOutputWriter) enqueue(finding webhookFinding) {
+	w.mu.Lock()
+	w.pending = append(w.pending, finding)
+	full := len(w.pending) >= webhookFindingsBatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// batchLoop periodically flushes the pending batch until stop is closed,
+// at which point it flushes once more to drain anything left.
+func (w *webhookOutputWriter) batchLoop() {
+	defer close(w.batchLoopDone)
+
+	ticker := time.NewTicker(webhookFindingsFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flushPending()
+		case <-w.flush:
+			w.flushPending()
+		case <-w.stop:
+			w.flushPending()
+			return
+		}
+	}
+}
+
+func (w *webhookOutputWriter) flushPending() {
+	w.mu.Lock()
+	if len(w.pending) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	findings := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	w.notifier.notify(webhookEventFinding, webhookPayload{Findings: findings})
+}
+
+// Close stops the batch loop - flushing any findings still pending - before
+// closing the wrapped output.Writer.
+func (w *webhookOutputWriter) Close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.batchLoopDone
+	})
+	w.Writer.Close()
+}
@@ -0,0 +1,170 @@
+package scans
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifierSign(t *testing.T) {
+	notifier := newWebhookNotifier(1, WebhookConfig{Secret: "s3cr3t"})
+
+	body := []byte(`{"event":"scan.finding"}`)
+	got := notifier.sign(body)
+
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got != want {
+		t.Fatalf("sign() = %q, want %q", got, want)
+	}
+}
+
+func TestWebhookNotifierSelectFieldsNoFilter(t *testing.T) {
+	notifier := newWebhookNotifier(1, WebhookConfig{})
+
+	payload := webhookPayload{Event: webhookEventFinding, ScanID: 1, Findings: []webhookFinding{{Target: "https://example.com"}}}
+	got := notifier.selectFields(payload)
+
+	if _, ok := got.(webhookPayload); !ok {
+		t.Fatalf("expected selectFields to return the payload unchanged when no field selector is configured, got %T", got)
+	}
+}
+
+func TestWebhookNotifierSelectFieldsFilters(t *testing.T) {
+	notifier := newWebhookNotifier(1, WebhookConfig{Fields: []string{"findings"}})
+
+	payload := webhookPayload{Event: webhookEventFinding, ScanID: 1, Findings: []webhookFinding{{Target: "https://example.com", Severity: "high"}}}
+	got, ok := notifier.selectFields(payload).(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected selectFields to return a filtered map, got %T", notifier.selectFields(payload))
+	}
+
+	for _, want := range []string{"event", "scan_id", "findings"} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("expected filtered payload to keep field %q", want)
+		}
+	}
+}
+
+func TestWebhookNotifierDeliverWithRetrySucceedsAfterFailures(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := newWebhookNotifier(1, WebhookConfig{
+		URL:         server.URL,
+		MaxRetries:  5,
+		BackoffBase: time.Millisecond,
+	})
+
+	notifier.deliverWithRetry(webhookEventFinding, webhookPayload{Event: webhookEventFinding})
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected delivery to succeed on the 3rd attempt, server saw %d attempts", got)
+	}
+}
+
+func TestWebhookNotifierDeliverWithRetryGivesUp(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := newWebhookNotifier(1, WebhookConfig{
+		URL:         server.URL,
+		MaxRetries:  3,
+		BackoffBase: time.Millisecond,
+	})
+
+	notifier.deliverWithRetry(webhookEventFinding, webhookPayload{Event: webhookEventFinding})
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected exactly MaxRetries (3) attempts, got %d", got)
+	}
+}
+
+func newTestWebhookOutputWriter(url string) *webhookOutputWriter {
+	notifier := newWebhookNotifier(1, WebhookConfig{URL: url, MaxRetries: 1, BackoffBase: time.Millisecond})
+	return &webhookOutputWriter{
+		notifier:      notifier,
+		flush:         make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		batchLoopDone: make(chan struct{}),
+	}
+}
+
+func startDeliveryServer(t *testing.T) (*httptest.Server, <-chan int) {
+	t.Helper()
+	delivered := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Findings []webhookFinding `json:"findings"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		delivered <- len(payload.Findings)
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, delivered
+}
+
+func TestWebhookOutputWriterFlushesOnBatchSize(t *testing.T) {
+	server, delivered := startDeliveryServer(t)
+	defer server.Close()
+
+	w := newTestWebhookOutputWriter(server.URL)
+	go w.batchLoop()
+	defer close(w.stop)
+
+	for i := 0; i < webhookFindingsBatchSize; i++ {
+		w.enqueue(webhookFinding{Target: "https://example.com"})
+	}
+
+	select {
+	case n := <-delivered:
+		if n != webhookFindingsBatchSize {
+			t.Fatalf("expected a single batched delivery of %d findings, got %d", webhookFindingsBatchSize, n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the batch-size flush to deliver")
+	}
+}
+
+func TestWebhookOutputWriterStopFlushesPending(t *testing.T) {
+	server, delivered := startDeliveryServer(t)
+	defer server.Close()
+
+	w := newTestWebhookOutputWriter(server.URL)
+	go w.batchLoop()
+
+	w.enqueue(webhookFinding{Target: "https://example.com"})
+
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.batchLoopDone
+	})
+
+	select {
+	case n := <-delivered:
+		if n != 1 {
+			t.Fatalf("expected the single pending finding to be flushed on stop, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for stop to flush the pending finding")
+	}
+}
@@ -3,9 +3,12 @@ package scans
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/projectdiscovery/gologger"
@@ -18,10 +21,26 @@ import (
 	"github.com/projectdiscovery/nuclei/v2/pkg/templates"
 	"github.com/projectdiscovery/nuclei/v2/pkg/types"
 	"github.com/projectdiscovery/nuclei/v2/pkg/web/api/services/settings"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/ratelimit"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// heartbeatInterval is how often a running scan renews its lease by
+	// writing last_heartbeat_at to the scans row.
+	heartbeatInterval = 15 * time.Second
+	// orphanHeartbeatThreshold is how stale a "running" scan's heartbeat
+	// must be before it is considered orphaned (e.g. nuclei-web crashed
+	// or was restarted mid-scan).
+	orphanHeartbeatThreshold = 4 * heartbeatInterval
+	// forceCancelInterval is how long the worker waits for a soft-cancelled
+	// scan to drain in-flight requests before tearing it down forcibly.
+	forceCancelInterval = 2 * time.Minute
+)
+
 type PercentReturnFunc func() float64
 
 func makePercentReturnFunc(stats progress.Progress) PercentReturnFunc {
@@ -30,6 +49,28 @@ func makePercentReturnFunc(stats progress.Progress) PercentReturnFunc {
 	})
 }
 
+// runningScan holds the bookkeeping stored in ScanService.Running for a
+// scan that is currently executing.
+type runningScan struct {
+	percent PercentReturnFunc
+	cancel  context.CancelFunc
+}
+
+// CancelScan cancels an in-flight scan by ID. It returns false if the scan
+// is not currently running (already finished, or never started).
+func (s *ScanService) CancelScan(scanID int64) bool {
+	value, ok := s.Running.Load(scanID)
+	if !ok {
+		return false
+	}
+	running, ok := value.(runningScan)
+	if !ok {
+		return false
+	}
+	running.cancel()
+	return true
+}
+
 // getSettingsForName gets settings for name and returns a types.Options structure
 func (s *ScanService) getSettingsForName(name string) (*types.Options, error) {
 	setting, err := s.db.GetSettingByName(context.Background(), name)
@@ -44,18 +85,27 @@ func (s *ScanService) getSettingsForName(name string) (*types.Options, error) {
 	return typesOptions, nil
 }
 
-func (s *ScanService) createExecuterOpts(scanID int64, templatesDirectory string, typesOptions *types.Options) (*scanContext, error) {
+func (s *ScanService) createExecuterOpts(ctx context.Context, scanID int64, templatesDirectory string, typesOptions *types.Options, webhookConfig WebhookConfig) (*scanContext, error) {
 	// Use a no ticking progress service to track scan statistics
 	progressImpl, _ := progress.NewStatsTicker(0, false, false, false, 0)
-	s.Running.Store(scanID, makePercentReturnFunc(progressImpl))
+
+	scanCtx, cancel := context.WithCancel(ctx)
 
 	logWriter, err := s.Logs.Write(scanID)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 	buflogWriter := bufio.NewWriter(logWriter)
 
-	outputWriter := newWrappedOutputWriter(s.db, buflogWriter, scanID)
+	// Only register the scan in Running once it can actually run - storing
+	// it any earlier would leave a stale entry (with an already-cancelled
+	// cancel func) behind forever on this error path, since worker() only
+	// defers scanCtx.Close() after createExecuterOpts returns successfully.
+	s.Running.Store(scanID, runningScan{percent: makePercentReturnFunc(progressImpl), cancel: cancel})
+
+	notifier := newWebhookNotifier(scanID, webhookConfig)
+	outputWriter := newWebhookOutputWriter(newWrappedOutputWriter(s.db, buflogWriter, scanID), notifier)
 
 	executerOpts := protocols.ExecuterOptions{
 		Output:       outputWriter,
@@ -70,6 +120,10 @@ func (s *ScanService) createExecuterOpts(scanID int64, templatesDirectory string
 		executerOpts.RateLimiter = ratelimit.New(typesOptions.RateLimit)
 	}
 	scanContext := &scanContext{
+		ctx:          scanCtx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+		notifier:     notifier,
 		logs:         buflogWriter,
 		logsFile:     logWriter,
 		scanID:       scanID,
@@ -82,6 +136,11 @@ func (s *ScanService) createExecuterOpts(scanID int64, templatesDirectory string
 
 // scanContext contains context information for a scan
 type scanContext struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	done         chan struct{}
+	closeOnce    sync.Once
+	notifier     *webhookNotifier
 	scanID       int64
 	executer     *core.Engine
 	store        *loader.Store
@@ -92,8 +151,31 @@ type scanContext struct {
 	executerOpts protocols.ExecuterOptions
 }
 
-// Close closes the scan context performing cleanup operations
+// Close closes the scan context performing cleanup operations. It is safe
+// to call more than once - only the first call takes effect - and safe to
+// call while scanCtx.executer.Execute is still running in an abandoned
+// goroutine (the force-cancel path in execute): in that case the actual
+// flush/close is deferred until that goroutine exits (scanCtx.done closes)
+// so we never close logsFile out from under an in-flight write.
 func (s *scanContext) Close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		select {
+		case <-s.done:
+			s.finish()
+		default:
+			go func() {
+				<-s.done
+				s.finish()
+			}()
+		}
+	})
+}
+
+// finish performs the actual teardown of scan resources. It must only run
+// once scanCtx.done is closed, i.e. once scanCtx.executer.Execute has
+// actually returned.
+func (s *scanContext) finish() {
 	s.logs.Flush()
 	s.logsFile.Close()
 	s.scanService.Running.Delete(s.scanID)
@@ -102,7 +184,7 @@ func (s *scanContext) Close() {
 }
 
 // createExecuterFromOpts creates executer from scanContext
-func (s *ScanService) createExecuterFromOpts(scanCtx *scanContext) error {
+func (s *ScanService) createExecuterFromOpts(ctx context.Context, scanCtx *scanContext) error {
 	workflowLoader, err := parsers.NewLoader(&scanCtx.executerOpts)
 	if err != nil {
 		return err
@@ -113,7 +195,10 @@ func (s *ScanService) createExecuterFromOpts(scanCtx *scanContext) error {
 	if err != nil {
 		return err
 	}
+
+	_, loadSpan := tracer.Start(ctx, "templates-load")
 	store.Load()
+	loadSpan.End()
 	scanCtx.store = store
 
 	executer := core.New(scanCtx.typesOptions)
@@ -122,18 +207,118 @@ func (s *ScanService) createExecuterFromOpts(scanCtx *scanContext) error {
 	return nil
 }
 
-// worker is a worker for executing a scan request
-func (s *ScanService) worker(req ScanRequest) error {
+// execute runs the loaded templates against the input provider, honouring
+// scanCtx.ctx cancellation. On cancellation it waits for the execution
+// goroutine to return before returning itself. core.Engine.Execute takes no
+// context, so the executer is not told to stop: it runs on until it finishes
+// by itself. If it does not finish within forceCancelInterval of the
+// cancellation, the scan is torn down forcibly instead of waiting indefinitely.
+func (s *ScanService) execute(scanCtx *scanContext, finalTemplates []*templates.Template, inputProvider core.InputProvider) error {
+	// executeCtx carries the execute span so that, once core.Engine.Execute
+	// accepts a context, per-template child spans can attach to it without
+	// further plumbing here.
+	executeCtx, executeSpan := tracer.Start(scanCtx.ctx, "execute",
+		trace.WithAttributes(attribute.Int("template.count", len(finalTemplates))))
+	defer executeSpan.End()
+	scanCtx.ctx = executeCtx
+
+	heartbeatDone := make(chan struct{})
+	go s.heartbeat(scanCtx, heartbeatDone)
+	defer close(heartbeatDone)
+
+	progressDone := make(chan struct{})
+	go s.sampleProgress(scanCtx, progressDone)
+	defer close(progressDone)
+
+	// NOTE: per-template spans with attributes such as template.id,
+	// template.severity and http.status_code require core.Engine.Execute
+	// itself to accept a context and emit per-template callbacks, which is
+	// outside this package - executeCtx is threaded through so that support
+	// can be added upstream without further plumbing here.
+	go func() {
+		defer close(scanCtx.done)
+		_ = scanCtx.executer.Execute(finalTemplates, inputProvider)
+	}()
+
+	select {
+	case <-scanCtx.done:
+		return nil
+	case <-scanCtx.ctx.Done():
+		gologger.Info().Msgf("[scans] [worker] [%d] scan cancelled, waiting for in-flight requests to drain", scanCtx.scanID)
+		select {
+		case <-scanCtx.done:
+			if markErr := s.db.UpdateScanState(context.Background(), scanCtx.scanID, "aborted"); markErr != nil {
+				gologger.Warning().Msgf("[scans] [worker] [%d] could not mark scan as aborted: %s", scanCtx.scanID, markErr)
+			}
+			return scanCtx.ctx.Err()
+		case <-time.After(forceCancelInterval):
+			gologger.Warning().Msgf("[scans] [worker] [%d] scan did not stop within %s of cancellation, forcing teardown", scanCtx.scanID, forceCancelInterval)
+			if markErr := s.db.UpdateScanState(context.Background(), scanCtx.scanID, "failed"); markErr != nil {
+				gologger.Warning().Msgf("[scans] [worker] [%d] could not mark scan as failed: %s", scanCtx.scanID, markErr)
+			}
+			// scanCtx.executer.Execute is still running in the abandoned
+			// goroutine above. Close() defers the actual flush/close until
+			// it eventually exits (scanCtx.done closes), so we never race
+			// a still-writing goroutine against a closed log file. The
+			// worker()'s own `defer scanCtx.Close()` will be a no-op after
+			// this, since Close() only runs its teardown once.
+			scanCtx.Close()
+			forceErr := fmt.Errorf("scan %d force-cancelled after exceeding %s", scanCtx.scanID, forceCancelInterval)
+			executeSpan.RecordError(forceErr)
+			executeSpan.SetStatus(codes.Error, forceErr.Error())
+			return forceErr
+		}
+	}
+}
+
+// heartbeat periodically renews the scan's lease by writing
+// last_heartbeat_at to the scans row, until done is closed. This lets
+// ScanService detect and reap scans left behind by a crashed or restarted
+// worker.
+func (s *ScanService) heartbeat(scanCtx *scanContext, done <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.db.UpdateScanHeartbeat(context.Background(), scanCtx.scanID); err != nil {
+				gologger.Warning().Msgf("[scans] [worker] [%d] failed to write heartbeat: %s", scanCtx.scanID, err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// worker is a worker for executing a scan request. ctx is the ScanService's
+// own lifecycle context - cancelled on process shutdown (OS signal) or an
+// external deadline - and is the parent of the scan's cancellable context,
+// alongside the per-scan cancellation wired through CancelScan.
+func (s *ScanService) worker(ctx context.Context, req ScanRequest) error {
+	ctx, rootSpan := tracer.Start(ctx, "scan",
+		trace.WithAttributes(attribute.Int64("scan.id", req.ScanID)))
+	defer rootSpan.End()
+
 	gologger.Info().Msgf("[scans] [worker] [%d] got new scan request", req.ScanID)
+	newWebhookNotifier(req.ScanID, req.Webhook).notify(webhookEventQueued, webhookPayload{})
 
+	_, settingsSpan := tracer.Start(ctx, "settings-load")
 	typesOptions, err := s.getSettingsForName(req.Config)
+	settingsSpan.End()
 	if err != nil {
+		rootSpan.RecordError(err)
+		rootSpan.SetStatus(codes.Error, err.Error())
 		return err
 	}
 	gologger.Info().Msgf("[scans] [worker] [%d] loaded settings for config %s", req.ScanID, req.Config)
 
+	_, templatesSpan := tracer.Start(ctx, "templates-fetch")
 	templatesDirectory, templatesList, workflowsList, err := s.storeTemplatesFromRequest(req.Templates)
+	templatesSpan.End()
 	if err != nil {
+		rootSpan.RecordError(err)
+		rootSpan.SetStatus(codes.Error, err.Error())
 		return err
 	}
 	defer os.RemoveAll(templatesDirectory)
@@ -144,14 +329,26 @@ func (s *ScanService) worker(req ScanRequest) error {
 	typesOptions.Templates = templatesList
 	typesOptions.Workflows = workflowsList
 
-	scanCtx, err := s.createExecuterOpts(req.ScanID, templatesDirectory, typesOptions)
+	scanCtx, err := s.createExecuterOpts(ctx, req.ScanID, templatesDirectory, typesOptions, req.Webhook)
 	if err != nil {
+		rootSpan.RecordError(err)
+		rootSpan.SetStatus(codes.Error, err.Error())
 		return err
 	}
-	defer scanCtx.Close()
+	// scanCtx.done is only closed by execute's goroutine; if the scan stops
+	// before execute runs, close it here so Close tears down immediately.
+	executeStarted := false
+	defer func() {
+		if !executeStarted {
+			close(scanCtx.done)
+		}
+		scanCtx.Close()
+	}()
 
-	err = s.createExecuterFromOpts(scanCtx)
+	err = s.createExecuterFromOpts(ctx, scanCtx)
 	if err != nil {
+		rootSpan.RecordError(err)
+		rootSpan.SetStatus(codes.Error, err.Error())
 		return err
 	}
 
@@ -161,19 +358,40 @@ func (s *ScanService) worker(req ScanRequest) error {
 
 	gologger.Info().Msgf("[scans] [worker] [%d] total loaded templates count: %d", req.ScanID, len(finalTemplates))
 
+	_, inputSpan := tracer.Start(ctx, "input-load")
 	inputProvider, err := s.inputProviderFromRequest(req.Targets)
+	inputSpan.End()
 	if err != nil {
+		rootSpan.RecordError(err)
+		rootSpan.SetStatus(codes.Error, err.Error())
 		return err
 	}
 	gologger.Info().Msgf("[scans] [worker] [%d] total loaded input count: %d", req.ScanID, inputProvider.Count())
 
 	scanCtx.executerOpts.Progress.Init(inputProvider.Count(), len(finalTemplates), int64(len(finalTemplates)*int(inputProvider.Count())))
-	_ = scanCtx.executer.Execute(finalTemplates, inputProvider)
+	scanCtx.notifier.notify(webhookEventStarted, webhookPayload{})
+
+	scanStartedAt := time.Now()
+	executeStarted = true
+	if err := s.execute(scanCtx, finalTemplates, inputProvider); err != nil {
+		gologger.Info().Msgf("[scans] [worker] [%d] scan stopped: %s", req.ScanID, err)
+		rootSpan.RecordError(err)
+		rootSpan.SetStatus(codes.Error, err.Error())
+		if errors.Is(err, context.Canceled) {
+			scanCtx.notifier.notify(webhookEventCancelled, webhookPayload{})
+		} else {
+			scanCtx.notifier.notify(webhookEventFailed, webhookPayload{})
+		}
+		return err
+	}
 
 	gologger.Info().Msgf("[scans] [worker] [%d] finished scan for ID", req.ScanID)
 
-	for k, v := range scanCtx.executerOpts.Progress.GetMetrics() {
+	metrics := scanCtx.executerOpts.Progress.GetMetrics()
+	for k, v := range metrics {
 		gologger.Info().Msgf("[scans] [worker] [%d] \tmetric '%s': %v", req.ScanID, k, v)
 	}
+	recordScanMetrics(metrics, time.Since(scanStartedAt))
+	scanCtx.notifier.notify(webhookEventCompleted, webhookPayload{})
 	return nil
 }
@@ -0,0 +1,101 @@
+package scans
+
+import (
+	"bufio"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWriteCloser counts how many times Close is called and records whether
+// any Write happened after it, so tests can detect a close/write race.
+type fakeWriteCloser struct {
+	mu              sync.Mutex
+	closed          bool
+	closes          int32
+	wroteAfterClose bool
+}
+
+func (f *fakeWriteCloser) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		f.wroteAfterClose = true
+	}
+	return len(p), nil
+}
+
+func (f *fakeWriteCloser) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	atomic.AddInt32(&f.closes, 1)
+	return nil
+}
+
+func newTestScanContext(scanID int64) (*scanContext, *fakeWriteCloser) {
+	file := &fakeWriteCloser{}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scanContext{
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		scanID:      scanID,
+		logs:        bufio.NewWriter(file),
+		logsFile:    file,
+		scanService: &ScanService{},
+	}, file
+}
+
+func TestScanContextCloseIsIdempotent(t *testing.T) {
+	scanCtx, file := newTestScanContext(1)
+	close(scanCtx.done) // simulate executer having already returned
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanCtx.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&file.closes); got != 1 {
+		t.Fatalf("expected logsFile.Close to run exactly once, got %d", got)
+	}
+}
+
+func TestScanContextCloseWaitsForAbandonedExecuteGoroutine(t *testing.T) {
+	scanCtx, file := newTestScanContext(2)
+
+	// scanCtx.done is not yet closed, mirroring the force-cancel path where
+	// scanCtx.executer.Execute is still running in an abandoned goroutine.
+	scanCtx.Close()
+
+	if atomic.LoadInt32(&file.closes) != 0 {
+		t.Fatal("expected Close to defer teardown until the abandoned goroutine exits")
+	}
+
+	// Simulate a late write from the still-running goroutine - it must not
+	// race against a concurrent Close of the same file.
+	_, _ = scanCtx.logs.WriteString("late write")
+	_ = scanCtx.logs.Flush()
+
+	close(scanCtx.done)
+
+	deadline := time.After(time.Second)
+	for atomic.LoadInt32(&file.closes) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for Close's deferred teardown to run")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if file.wroteAfterClose {
+		t.Fatal("a write raced against logsFile.Close")
+	}
+}